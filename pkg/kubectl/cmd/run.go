@@ -0,0 +1,997 @@
+/*
+Copyright 2014 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/util/homedir"
+
+	"k8s.io/kubernetes/pkg/api/legacyscheme"
+	api "k8s.io/kubernetes/pkg/apis/core"
+	"k8s.io/kubernetes/pkg/kubectl"
+	cmdutil "k8s.io/kubernetes/pkg/kubectl/cmd/util"
+	"k8s.io/kubernetes/pkg/kubectl/cmd/util/openapi"
+	"k8s.io/kubernetes/pkg/kubectl/resource"
+	"k8s.io/kubernetes/pkg/kubectl/scheme"
+	"k8s.io/kubernetes/pkg/kubectl/util/i18n"
+	"k8s.io/kubernetes/pkg/kubectl/validation"
+	"k8s.io/kubernetes/pkg/printers"
+)
+
+const (
+	runLong = `Create and run a particular image in a pod.`
+
+	runExample = `
+	# Start a single instance of nginx.
+	kubectl run nginx --image=nginx
+
+	# Start a single instance of hazelcast and wait up to 2 minutes for it to become ready.
+	kubectl run hazelcast --image=hazelcast --wait --timeout=2m`
+
+	// defaultRunTimeout is how long "kubectl run --wait" blocks before giving up
+	// on the workload ever becoming ready.
+	defaultRunTimeout = 5 * time.Minute
+
+	// runWaitPollInterval is how often the readiness poll re-lists the tracked
+	// object while --wait is in effect.
+	runWaitPollInterval = 2 * time.Second
+)
+
+// defaultSchemaCacheDir is where the fetched OpenAPI schema is memoised, one
+// file per server version, unless --schema-cache-dir overrides it. Resolved
+// against the user's actual home directory since Go (unlike a shell) won't
+// expand a literal "~".
+var defaultSchemaCacheDir = filepath.Join(homedir.HomeDir(), ".kube", "cache", "run-schema")
+
+// addWaitFlags registers the common "block until done" flag set: --wait, its
+// standard --no-wait negation, and a deprecated --async alias kept around for
+// scripts written against the old flag name. subject is used only to make the
+// generated help text read naturally (e.g. "created Pod(s)/Deployment/Job").
+// Any command that wants --wait semantics should call this instead of adding
+// its own bool flag, so the precedence rules in resolveWaitFlag stay uniform.
+//
+// --async deliberately isn't registered via cmd.Flags().MarkDeprecated: pflag
+// prints its own deprecation notice to stderr the moment the flag is parsed,
+// and resolveWaitFlag prints a second one to errOut when it resolves the
+// flag - a user passing --async would see the warning twice. resolveWaitFlag
+// owns the single deprecation message instead.
+func addWaitFlags(cmd *cobra.Command, subject string) {
+	cmd.Flags().Bool("wait", false, fmt.Sprintf("If true, wait for the %s to report ready before returning, up to --timeout. Mutually exclusive with --no-wait.", subject))
+	cmd.Flags().Bool("no-wait", false, fmt.Sprintf("If true, return immediately after creating the %s instead of waiting for it to report ready. Mutually exclusive with --wait.", subject))
+	cmd.Flags().Bool("async", false, "If true, return immediately after creating the object. Deprecated: use --no-wait instead.")
+}
+
+// resolveWaitFlag applies --wait/--no-wait/--async to a single tri-state
+// answer: nil means the user didn't ask either way, so the caller should fall
+// through to its own config or generator default. Using --async prints a
+// deprecation notice to errOut each time it's resolved.
+func resolveWaitFlag(cmd *cobra.Command, errOut io.Writer) (*bool, error) {
+	waitSet := cmd.Flags().Changed("wait")
+	noWaitSet := cmd.Flags().Changed("no-wait")
+
+	if waitSet && noWaitSet {
+		return nil, cmdutil.UsageErrorf(cmd, "--wait and --no-wait are mutually exclusive")
+	}
+
+	if cmd.Flags().Changed("async") {
+		fmt.Fprintf(errOut, "Flag --async has been deprecated, use --no-wait instead\n")
+		async := cmdutil.GetFlagBool(cmd, "async")
+		wait := !async
+		return &wait, nil
+	}
+
+	if waitSet {
+		wait := cmdutil.GetFlagBool(cmd, "wait")
+		return &wait, nil
+	}
+	if noWaitSet {
+		wait := !cmdutil.GetFlagBool(cmd, "no-wait")
+		return &wait, nil
+	}
+	return nil, nil
+}
+
+// RunOpts holds the options for 'run' sub command
+type RunOpts struct {
+	PrintFlags    *printers.PrintFlags
+	DeleteOptions *DeleteOptions
+
+	PrintObj func(runtime.Object) error
+
+	In     io.Reader
+	Out    io.Writer
+	ErrOut io.Writer
+
+	Generator        string
+	Image            string
+	Port             string
+	Labels           string
+	Command          bool
+	Namespace        string
+	Overrides        string
+	Attach           bool
+	Rm               bool
+	Interactive      bool
+	TTY              bool
+	Replicas         int
+	DryRun           bool
+	Record           bool
+	ArgsLenAtDash    int
+	ServiceGenerator string
+
+	// Wait is the resolved --wait/--no-wait/--async state: nil means the user
+	// didn't pass any of those flags, so resolveShouldWait falls through to a
+	// config default and then the generator's own default. Timeout bounds how
+	// long a "yes, wait" answer blocks for.
+	Wait    *bool
+	Timeout time.Duration
+
+	// Validate and SchemaCacheDir control client-side OpenAPI validation of
+	// the generated object before it is POSTed to the server.
+	Validate       bool
+	SchemaCacheDir string
+
+	// Prune and InventoryName enable "live apply with inventory": repeated
+	// invocations with the same InventoryName converge the namespace to
+	// exactly the objects this invocation creates, deleting anything a prior
+	// invocation created that is no longer produced.
+	Prune         bool
+	InventoryName string
+}
+
+// waitTimeoutError is returned once Timeout elapses before the tracked
+// workload becomes ready. For a pod wait it names the pods that were not
+// ready; a Job or Deployment wait leaves that list empty.
+type waitTimeoutError struct {
+	kind     string
+	name     string
+	notReady []string
+	timeout  time.Duration
+}
+
+func (e *waitTimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s waiting for %s %q: pod(s) not ready: %v", e.timeout, e.kind, e.name, e.notReady)
+}
+
+// NewCmdRun creates the run command
+func NewCmdRun(f cmdutil.Factory, in io.Reader, out, errout io.Writer) *cobra.Command {
+	opts := &RunOpts{
+		PrintFlags: printers.NewPrintFlags("created"),
+		In:         in,
+		Out:        out,
+		ErrOut:     errout,
+	}
+
+	cmd := &cobra.Command{
+		Use:     "run NAME --image=image [--env=\"key=value\"] [--port=port] [--replicas=replicas] [--dry-run=bool] [--overrides=inline-json] [--command] -- [COMMAND] [args...]",
+		Short:   i18n.T("Run a particular image on the cluster"),
+		Long:    runLong,
+		Example: runExample,
+		Run: func(cmd *cobra.Command, args []string) {
+			opts.ArgsLenAtDash = cmd.ArgsLenAtDash()
+			cmdutil.CheckErr(opts.Complete(f, cmd))
+			cmdutil.CheckErr(opts.Run(f, cmd, args))
+		},
+	}
+
+	addRunFlags(cmd)
+	opts.PrintFlags.AddFlags(cmd)
+	cmdutil.AddDryRunFlag(cmd)
+	cmdutil.AddApplyAnnotationFlags(cmd)
+	cmdutil.AddRecordFlag(cmd)
+	return cmd
+}
+
+// addRunFlags registers the flags shared by "kubectl run".
+func addRunFlags(cmd *cobra.Command) {
+	cmd.Flags().String("generator", "", i18n.T("The name of the API generator to use."))
+	cmd.Flags().String("image", "", i18n.T("The image for the container to run."))
+	cmd.Flags().String("image-pull-policy", "", i18n.T("The image pull policy for the container."))
+	cmd.Flags().Int("replicas", 1, "Number of replicas to create for this container. Default is 1.")
+	cmd.Flags().Bool("rm", false, "If true, do not print the created resources. Only allowed with --attach or -i/--stdin. The created resources are not deleted.")
+	cmd.Flags().String("overrides", "", i18n.T("An inline JSON override for the generated object."))
+	cmd.Flags().StringSlice("env", []string{}, "Environment variables to set in the container.")
+	cmd.Flags().String("port", "", i18n.T("The port that this container exposes."))
+	cmd.Flags().Int("hostport", -1, "The host port mapping for the container port. To demonstrate a single-machine container.")
+	cmd.Flags().StringP("labels", "l", "", "Comma separated labels to apply to the pod(s). Will override previous values.")
+	cmd.Flags().BoolP("stdin", "i", false, "Keep stdin open on the container(s) in the pod, even if nothing is attached.")
+	cmd.Flags().BoolP("tty", "t", false, "Allocate a TTY for the container in the pod.")
+	cmd.Flags().Bool("attach", false, "If true, treat the run as attached: --rm is allowed and --dry-run is rejected. This command does not attach to the Pod.")
+	cmd.Flags().Bool("leave-stdin-open", false, "If the pod is started in interactive mode or with stdin, leave stdin open after the first attach completes.")
+	cmd.Flags().String("restart", "Always", i18n.T("The restart policy for this Pod.  Legal values [Always, OnFailure, Never]."))
+	cmd.Flags().Bool("command", false, "If true and extra arguments are present, use them as the 'command' field in the container, rather than the 'args' field which is the default.")
+	cmd.Flags().String("requests", "", i18n.T("The resource requirement requests for this container."))
+	cmd.Flags().String("limits", "", i18n.T("The resource requirement limits for this container."))
+	cmd.Flags().Bool("expose", false, "If true, a public, external service is created for the container(s) which are run")
+	cmd.Flags().String("service-generator", "service/v2", i18n.T("The name of the generator to use for creating a service."))
+	cmd.Flags().String("service-overrides", "", i18n.T("An inline JSON override for the generated service object."))
+	addWaitFlags(cmd, "created Pod(s)/Deployment/Job")
+	cmd.Flags().Duration("timeout", defaultRunTimeout, "The length of time to wait for the workload to become ready when waiting is enabled.")
+	cmd.Flags().Bool("validate", true, "If true, validate the generated object against the server's OpenAPI schema before creating it.")
+	cmd.Flags().String("schema-cache-dir", defaultSchemaCacheDir, "If non-empty, cache the OpenAPI schema fetched for --validate under this directory, keyed by server version.")
+	cmd.Flags().Bool("prune", false, "If true, reconcile the namespace to exactly the objects this invocation creates: objects an earlier run with the same --inventory-name created that this run no longer produces are deleted.")
+	cmd.Flags().String("inventory-name", "", "Name of the ConfigMap used to track objects created by repeated --prune invocations. Required when --prune is set.")
+}
+
+// Complete verifies command line arguments and loads data from the command environment
+func (o *RunOpts) Complete(f cmdutil.Factory, cmd *cobra.Command) error {
+	namespace, _, err := f.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return err
+	}
+	o.Namespace = namespace
+
+	o.Generator = cmdutil.GetFlagString(cmd, "generator")
+	o.Image = cmdutil.GetFlagString(cmd, "image")
+	o.Port = cmdutil.GetFlagString(cmd, "port")
+	o.Labels = cmdutil.GetFlagString(cmd, "labels")
+	o.Command = cmdutil.GetFlagBool(cmd, "command")
+	o.Overrides = cmdutil.GetFlagString(cmd, "overrides")
+	o.Attach = cmdutil.GetFlagBool(cmd, "attach")
+	o.Rm = cmdutil.GetFlagBool(cmd, "rm")
+	o.Interactive = cmdutil.GetFlagBool(cmd, "stdin")
+	o.TTY = cmdutil.GetFlagBool(cmd, "tty")
+	o.Replicas = cmdutil.GetFlagInt(cmd, "replicas")
+	o.Record = cmdutil.GetRecordFlag(cmd)
+	o.ServiceGenerator = cmdutil.GetFlagString(cmd, "service-generator")
+	o.DryRun = cmdutil.GetDryRunFlag(cmd)
+	wait, err := resolveWaitFlag(cmd, o.ErrOut)
+	if err != nil {
+		return err
+	}
+	o.Wait = wait
+	o.Timeout = cmdutil.GetFlagDuration(cmd, "timeout")
+	o.Validate = cmdutil.GetFlagBool(cmd, "validate")
+	o.SchemaCacheDir = cmdutil.GetFlagString(cmd, "schema-cache-dir")
+	o.Prune = cmdutil.GetFlagBool(cmd, "prune")
+	o.InventoryName = cmdutil.GetFlagString(cmd, "inventory-name")
+
+	printer, err := o.PrintFlags.ToPrinter()
+	if err != nil {
+		return err
+	}
+	o.PrintObj = func(obj runtime.Object) error {
+		return printer.PrintObj(obj, o.Out)
+	}
+
+	deleteFlags := NewDeleteFlags("to use to replace the resource.")
+	o.DeleteOptions = deleteFlags.ToOptions(o.Out, o.ErrOut)
+	return nil
+}
+
+// Run performs the execution of 'run' sub command
+func (o *RunOpts) Run(f cmdutil.Factory, cmd *cobra.Command, args []string) error {
+	if len(args) == 0 || len(args[0]) == 0 {
+		return cmdutil.UsageErrorf(cmd, "NAME is required for run")
+	}
+	if len(o.Image) == 0 {
+		return cmdutil.UsageErrorf(cmd, "--image is required")
+	}
+	if err := verifyImageIsValid(o.Image); err != nil {
+		return err
+	}
+
+	if o.TTY && !o.Interactive {
+		return cmdutil.UsageErrorf(cmd, "stdin is required for containers with -t/--tty")
+	}
+	if o.Rm && !(o.Interactive || o.Attach) {
+		return cmdutil.UsageErrorf(cmd, "rm should only be used for attached containers")
+	}
+	if (o.Interactive || o.Attach) && o.DryRun {
+		return fmt.Errorf("--dry-run can't be used with attached containers options (--attach, -i, -t)")
+	}
+	if o.Interactive && o.Replicas != 1 {
+		return fmt.Errorf("-i/--stdin requires that replicas is 1, found %d", o.Replicas)
+	}
+	if o.Prune && len(o.InventoryName) == 0 {
+		return cmdutil.UsageErrorf(cmd, "--inventory-name is required when --prune is set")
+	}
+
+	name := args[0]
+	generatorName := o.Generator
+	if len(generatorName) == 0 {
+		generatorName = "run/v1"
+	}
+
+	generators := kubectl.GeneratorFn(generatorName)
+	generator, found := generators(name)
+	if !found {
+		return cmdutil.UsageErrorf(cmd, "generator %q not found", generatorName)
+	}
+
+	names := generator.ParamNames()
+	params := kubectl.MakeParams(cmd, names)
+	params["name"] = name
+	if len(o.Overrides) > 0 {
+		params["overrides"] = o.Overrides
+	}
+
+	obj, err := generator.Generate(params)
+	if err != nil {
+		return err
+	}
+
+	// --overrides is merged in here, before validateGeneratedObject, so that
+	// --validate actually checks the object that gets POSTed - not the
+	// pre-override object, which CreateOrUpdateObject would otherwise merge
+	// the overrides into after validation already ran.
+	if len(o.Overrides) > 0 {
+		obj, err = cmdutil.Merge(legacyscheme.Codecs.LegacyCodec(scheme.Versions...), obj, o.Overrides)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := o.validateGeneratedObject(f, obj); err != nil {
+		return err
+	}
+
+	if o.DryRun {
+		return o.PrintObj(obj)
+	}
+
+	actualObj, err := kubectl.CreateOrUpdateObject(f, obj, o.Namespace, "")
+	if err != nil {
+		return err
+	}
+
+	created := []runtime.Object{actualObj}
+	if cmdutil.GetFlagBool(cmd, "expose") {
+		serviceObj, err := o.generateService(f, cmd, o.ServiceGenerator, params, o.Namespace)
+		if err != nil {
+			return err
+		}
+		created = append(created, serviceObj)
+	}
+
+	if err := o.reconcileInventory(f, created); err != nil {
+		return err
+	}
+
+	if o.resolveShouldWait(generatorName) {
+		timeout := o.Timeout
+		if timeout == 0 {
+			timeout = defaultRunTimeout
+		}
+		if err := o.waitForReadiness(f, actualObj, timeout); err != nil {
+			return err
+		}
+	}
+
+	if o.Rm {
+		return nil
+	}
+
+	return o.PrintObj(actualObj)
+}
+
+// resolveShouldWait applies the documented precedence for "do we block on
+// completion": an explicit --wait/--no-wait/--async flag wins, then a config
+// default, then the generator's own default.
+func (o *RunOpts) resolveShouldWait(generatorName string) bool {
+	if o.Wait != nil {
+		return *o.Wait
+	}
+	if configDefault := o.configDefaultWait(); configDefault != nil {
+		return *configDefault
+	}
+	return generatorDefaultWait(generatorName)
+}
+
+// configDefaultWait is the extension point for a cluster- or user-configured
+// default (e.g. a kubeconfig extension); no such source exists yet, so it
+// always defers to the generator default.
+func (o *RunOpts) configDefaultWait() *bool {
+	return nil
+}
+
+// generatorDefaultWait returns the wait-by-default behavior for a generator
+// when neither a flag nor a config default picked one: one-shot Jobs are
+// expected to run to completion, so they default to waiting; long-running
+// workloads like Deployments default to returning immediately.
+func generatorDefaultWait(generatorName string) bool {
+	switch generatorName {
+	case "job/v1", "run-pod/v1":
+		return true
+	default:
+		return false
+	}
+}
+
+// waitForReadiness blocks until the pods backing obj are ready, or returns a
+// *waitTimeoutError once timeout elapses. Progress is reported to o.ErrOut as
+// each not-yet-ready pod is observed, mirroring the poll loop Helm's
+// pkg/kube/wait.go uses for its own "wait for ready" support.
+func (o *RunOpts) waitForReadiness(f cmdutil.Factory, obj runtime.Object, timeout time.Duration) error {
+	clientset, err := f.KubernetesClientSet()
+	if err != nil {
+		return err
+	}
+
+	switch t := obj.(type) {
+	case *v1.Pod:
+		return o.waitForPods(clientset, t.Namespace, "", "metadata.name="+t.Name, 1, timeout)
+	case *v1.ReplicationController:
+		sel := labels.SelectorFromSet(t.Spec.Selector).String()
+		return o.waitForPods(clientset, t.Namespace, sel, "", int(derefReplicas(t.Spec.Replicas)), timeout)
+	case *batchv1.Job:
+		return o.waitForJob(clientset, t, timeout)
+	case *appsv1.Deployment:
+		return o.waitForDeployment(clientset, t, timeout)
+	default:
+		// Generators we don't know how to wait on (e.g. Services) are a no-op.
+		return nil
+	}
+}
+
+// selectorDescription names whichever selector waitForPods actually used, for
+// the timeout error message.
+func selectorDescription(labelSelector, fieldSelector string) string {
+	if len(fieldSelector) > 0 {
+		return fieldSelector
+	}
+	return labelSelector
+}
+
+func derefReplicas(p *int32) int32 {
+	if p == nil {
+		return 1
+	}
+	return *p
+}
+
+// waitForPods polls the pods matching labelSelector/fieldSelector in
+// namespace until all wanted pods report Running with every container Ready,
+// or timeout elapses. Exactly one of labelSelector/fieldSelector is expected
+// to be non-empty: a bare Pod is tracked by its name via fieldSelector (it
+// doesn't carry a predictable label), while a ReplicationController's pods
+// are tracked via the label selector the controller manages.
+func (o *RunOpts) waitForPods(clientset kubernetes.Interface, namespace, labelSelector, fieldSelector string, wanted int, timeout time.Duration) error {
+	notReady := map[string]bool{}
+	err := wait.PollImmediate(runWaitPollInterval, timeout, func() (bool, error) {
+		list, err := clientset.CoreV1().Pods(namespace).List(metav1.ListOptions{LabelSelector: labelSelector, FieldSelector: fieldSelector})
+		if err != nil {
+			return false, err
+		}
+		if len(list.Items) < wanted {
+			return false, nil
+		}
+		notReady = map[string]bool{}
+		for _, pod := range list.Items {
+			if !podReady(&pod) {
+				notReady[pod.Name] = true
+				fmt.Fprintf(o.ErrOut, "waiting for pod %s (%d/%d ready)\n", pod.Name, readyContainers(&pod), len(pod.Spec.Containers))
+			}
+		}
+		return len(notReady) == 0, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		names := make([]string, 0, len(notReady))
+		for n := range notReady {
+			names = append(names, n)
+		}
+		return &waitTimeoutError{kind: "pod(s)", name: selectorDescription(labelSelector, fieldSelector), notReady: names, timeout: timeout}
+	}
+	return err
+}
+
+// waitForJob polls the Job until status.succeeded reaches the number of
+// completions the Job asked for.
+func (o *RunOpts) waitForJob(clientset kubernetes.Interface, job *batchv1.Job, timeout time.Duration) error {
+	completions := int32(1)
+	if job.Spec.Completions != nil {
+		completions = *job.Spec.Completions
+	}
+	err := wait.PollImmediate(runWaitPollInterval, timeout, func() (bool, error) {
+		current, err := clientset.BatchV1().Jobs(job.Namespace).Get(job.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		fmt.Fprintf(o.ErrOut, "waiting for job %s (%d/%d completed)\n", job.Name, current.Status.Succeeded, completions)
+		return current.Status.Succeeded >= completions, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return &waitTimeoutError{kind: "job", name: job.Name, timeout: timeout}
+	}
+	return err
+}
+
+// waitForDeployment polls the Deployment until readyReplicas matches the
+// requested replica count and the newest ReplicaSet owns those ready pods.
+func (o *RunOpts) waitForDeployment(clientset kubernetes.Interface, dep *appsv1.Deployment, timeout time.Duration) error {
+	wanted := derefReplicas(dep.Spec.Replicas)
+	err := wait.PollImmediate(runWaitPollInterval, timeout, func() (bool, error) {
+		current, err := clientset.AppsV1().Deployments(dep.Namespace).Get(dep.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if current.Status.ReadyReplicas != wanted {
+			fmt.Fprintf(o.ErrOut, "waiting for deployment %s (%d/%d ready)\n", dep.Name, current.Status.ReadyReplicas, wanted)
+			return false, nil
+		}
+		newestOwnsReady, err := newestReplicaSetOwnsReadyPods(clientset, current)
+		if err != nil {
+			return false, err
+		}
+		return newestOwnsReady, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return &waitTimeoutError{kind: "deployment", name: dep.Name, timeout: timeout}
+	}
+	return err
+}
+
+// newestReplicaSetOwnsReadyPods confirms that the ready pods we observed
+// belong to the most recently created ReplicaSet matching dep's selector, not
+// an older one left over from a previous rollout.
+func newestReplicaSetOwnsReadyPods(clientset kubernetes.Interface, dep *appsv1.Deployment) (bool, error) {
+	rsList, err := clientset.AppsV1().ReplicaSets(dep.Namespace).List(metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(dep.Spec.Selector.MatchLabels).String(),
+	})
+	if err != nil {
+		return false, err
+	}
+	var newest *appsv1.ReplicaSet
+	for i := range rsList.Items {
+		rs := &rsList.Items[i]
+		if newest == nil || rs.CreationTimestamp.After(newest.CreationTimestamp.Time) {
+			newest = rs
+		}
+	}
+	if newest == nil {
+		return false, nil
+	}
+	return newest.Status.ReadyReplicas == deploymentWantedReplicas(dep), nil
+}
+
+func deploymentWantedReplicas(dep *appsv1.Deployment) int32 {
+	return derefReplicas(dep.Spec.Replicas)
+}
+
+func podReady(pod *v1.Pod) bool {
+	if pod.Status.Phase != v1.PodRunning {
+		return false
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == v1.PodReady {
+			return c.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// readyContainers counts how many of pod's containers report Ready, for the
+// "(m/n ready)" progress line in waitForPods.
+func readyContainers(pod *v1.Pod) int {
+	ready := 0
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Ready {
+			ready++
+		}
+	}
+	return ready
+}
+
+// validateGeneratedObject checks obj against the server's OpenAPI schema
+// before it is POSTed, unless --validate=false was passed. Failures are
+// returned as actionable errors that name the offending field path (e.g.
+// "spec.containers[0].resources.limits.cpu: invalid quantity") rather than
+// waiting for the API server to reject the request.
+func (o *RunOpts) validateGeneratedObject(f cmdutil.Factory, obj runtime.Object) error {
+	if !o.Validate {
+		return nil
+	}
+
+	validator, err := o.openAPISchemaValidator(f)
+	if err != nil {
+		return fmt.Errorf("unable to load schema for --validate: %v", err)
+	}
+	return validateAgainstSchema(validator, obj)
+}
+
+// validateAgainstSchema marshals obj and runs it through validator, wrapping
+// any rejection in the same actionable error validateGeneratedObject returns
+// to the caller. Split out from validateGeneratedObject so the validation
+// step itself can be exercised with a stub validation.Schema, independent of
+// how that schema was obtained (live discovery, disk cache, or the bundled
+// fallback).
+func validateAgainstSchema(validator validation.Schema, obj runtime.Object) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	if err := validator.ValidateBytes(data); err != nil {
+		return fmt.Errorf("error validating generated object: %v", err)
+	}
+	return nil
+}
+
+// openAPISchemaValidator builds a validation.Schema backed by the live
+// cluster's OpenAPI document, falling back to kubectl's bundled schema when
+// discovery can't be reached (e.g. the cluster is unreachable or offline).
+func (o *RunOpts) openAPISchemaValidator(f cmdutil.Factory) (validation.Schema, error) {
+	discoveryClient, err := f.DiscoveryClient()
+	if err != nil {
+		return validation.NewConjunctiveSchema(validation.NoDoubleKeySchema{}), err
+	}
+
+	getter := &cachedOpenAPIGetter{
+		discovery: discoveryClient,
+		cacheDir:  o.SchemaCacheDir,
+	}
+	resources, err := getter.Get()
+	if err != nil {
+		// Offline or the cluster has no /openapi/v2 endpoint: fall back to the
+		// schema bundled with this kubectl binary so --validate still catches
+		// gross mistakes (bad quantities, unknown fields) without a live API server.
+		resources, err = openapi.NewOpenAPIData(openapi.BundledDocument())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return validation.ConjunctiveSchema{
+		validation.NewSchemaValidation(resources),
+		validation.NoDoubleKeySchema{},
+	}, nil
+}
+
+// cachedOpenAPIGetter fetches the cluster's /openapi/v2 document once per
+// server version and memoises it on disk under cacheDir, so repeated
+// "kubectl run --validate" invocations against the same cluster don't
+// re-fetch the full schema every time.
+type cachedOpenAPIGetter struct {
+	discovery discovery.DiscoveryInterface
+	cacheDir  string
+}
+
+func (g *cachedOpenAPIGetter) Get() (openapi.Resources, error) {
+	doc, err := g.cachedDocument()
+	if err != nil {
+		return nil, err
+	}
+	return openapi.NewOpenAPIData(doc)
+}
+
+// cachedDocument returns the raw OpenAPI document, reading it from
+// g.cacheDir when a copy for the server's current version already exists and
+// writing a freshly fetched copy back otherwise.
+func (g *cachedOpenAPIGetter) cachedDocument() (openapi.Document, error) {
+	version, err := g.discovery.ServerVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	cachePath := ""
+	if len(g.cacheDir) > 0 {
+		cachePath = filepath.Join(g.cacheDir, version.GitVersion+".json")
+		if data, err := ioutil.ReadFile(cachePath); err == nil {
+			return openapi.ParseDocument(data)
+		}
+	}
+
+	doc, err := g.discovery.OpenAPISchema()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cachePath) > 0 {
+		if data, err := openapi.MarshalDocument(doc); err == nil {
+			if err := os.MkdirAll(g.cacheDir, 0755); err == nil {
+				ioutil.WriteFile(cachePath, data, 0644)
+			}
+		}
+	}
+	return doc, nil
+}
+
+// generateService generates the service object for the given generator and
+// parameters, creating it on the server unless o.DryRun is set.
+func (o *RunOpts) generateService(f cmdutil.Factory, cmd *cobra.Command, serviceGenerator string, paramsIn map[string]interface{}, namespace string) (runtime.Object, error) {
+	if len(o.Port) == 0 {
+		return nil, fmt.Errorf("--port must be specified when exposing a service")
+	}
+
+	params := map[string]interface{}{}
+	for k, v := range paramsIn {
+		params[k] = v
+	}
+	params["port"] = o.Port
+
+	generators := kubectl.GeneratorFn(serviceGenerator)
+	generator, found := generators(params["name"].(string))
+	if !found {
+		return nil, fmt.Errorf("unknown service generator %q", serviceGenerator)
+	}
+
+	obj, err := generator.Generate(params)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.DryRun {
+		return obj, o.PrintObj(obj)
+	}
+
+	actualObj, err := kubectl.CreateOrUpdateObject(f, obj, namespace, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := o.PrintObj(actualObj); err != nil {
+		return nil, err
+	}
+	return actualObj, nil
+}
+
+// inventoryNewSuffix names the staging ConfigMap reconcileInventory writes
+// the new object set to before it prunes and swaps it into place, so a crash
+// mid-reconcile leaves behind a recoverable "-new" copy rather than a
+// half-updated inventory.
+const inventoryNewSuffix = "-new"
+
+// inventoryEntry identifies a single object tracked by a --prune inventory.
+type inventoryEntry struct {
+	Group     string
+	Version   string
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (e inventoryEntry) key() string {
+	return strings.Join([]string{e.Group, e.Version, e.Kind, e.Namespace, e.Name}, "_")
+}
+
+// gvkForObject returns obj's GroupVersionKind, preferring one already stamped
+// on obj (e.g. by a caller that round-tripped it through the REST client) and
+// otherwise falling back to a scheme lookup. Objects returned by
+// kubectl.CreateOrUpdateObject/generator.Generate carry a typed Go value with
+// an empty TypeMeta, so the scheme lookup is the common path in production.
+func gvkForObject(obj runtime.Object) (schema.GroupVersionKind, error) {
+	if gvk := obj.GetObjectKind().GroupVersionKind(); !gvk.Empty() {
+		return gvk, nil
+	}
+	gvks, _, err := scheme.Scheme.ObjectKinds(obj)
+	if err != nil {
+		return schema.GroupVersionKind{}, err
+	}
+	if len(gvks) == 0 {
+		return schema.GroupVersionKind{}, fmt.Errorf("no GroupVersionKind registered for %T", obj)
+	}
+	return gvks[0], nil
+}
+
+func inventoryEntryFor(obj runtime.Object, namespace string) (inventoryEntry, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return inventoryEntry{}, err
+	}
+	gvk, err := gvkForObject(obj)
+	if err != nil {
+		return inventoryEntry{}, err
+	}
+	ns := accessor.GetNamespace()
+	if len(ns) == 0 {
+		ns = namespace
+	}
+	return inventoryEntry{
+		Group:     gvk.Group,
+		Version:   gvk.Version,
+		Kind:      gvk.Kind,
+		Namespace: ns,
+		Name:      accessor.GetName(),
+	}, nil
+}
+
+// reconcileInventory implements "kubectl run --prune": it records the set of
+// objects this invocation created in a ConfigMap named InventoryName, and
+// deletes anything a prior invocation recorded there that this invocation did
+// not recreate. The ConfigMap is written in three steps - create the "-new"
+// staging copy, prune, then swap it into the real name - so a crash at any
+// point leaves a recoverable "-new" copy instead of a half-written inventory.
+func (o *RunOpts) reconcileInventory(f cmdutil.Factory, created []runtime.Object) error {
+	if !o.Prune {
+		return nil
+	}
+
+	clientset, err := f.KubernetesClientSet()
+	if err != nil {
+		return err
+	}
+	cms := clientset.CoreV1().ConfigMaps(o.Namespace)
+
+	entries := map[string]inventoryEntry{}
+	for _, obj := range created {
+		entry, err := inventoryEntryFor(obj, o.Namespace)
+		if err != nil {
+			return err
+		}
+		entries[entry.key()] = entry
+	}
+
+	previous, err := loadInventory(cms, o.InventoryName)
+	if err != nil {
+		return err
+	}
+
+	newCM := inventoryConfigMap(o.InventoryName+inventoryNewSuffix, entries)
+	if err := createOrUpdateConfigMap(cms, newCM); err != nil {
+		return err
+	}
+
+	for key, entry := range previous {
+		if _, stillPresent := entries[key]; stillPresent {
+			continue
+		}
+		if err := deleteInventoryEntry(f, entry); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	finalCM := inventoryConfigMap(o.InventoryName, entries)
+	if err := createOrUpdateConfigMap(cms, finalCM); err != nil {
+		return err
+	}
+
+	if err := cms.Delete(o.InventoryName+inventoryNewSuffix, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// loadInventory returns the inventory entries prior runs recorded: the union
+// of the real inventory and the "-new" staging ConfigMap, whichever exist. A
+// staging copy means a previous --prune run crashed after staging its new
+// object set but before pruning and swapping, so objects it created are only
+// in the staging copy while objects it dropped but never pruned are only in
+// the real inventory; both sets are reconciled against now.
+func loadInventory(cms corev1client.ConfigMapInterface, name string) (map[string]inventoryEntry, error) {
+	entries := map[string]inventoryEntry{}
+	for _, cmName := range []string{name, name + inventoryNewSuffix} {
+		cm, err := cms.Get(cmName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for key, entry := range parseInventory(cm) {
+			entries[key] = entry
+		}
+	}
+	return entries, nil
+}
+
+func parseInventory(cm *v1.ConfigMap) map[string]inventoryEntry {
+	entries := make(map[string]inventoryEntry, len(cm.Data))
+	for key, name := range cm.Data {
+		parts := strings.SplitN(key, "_", 5)
+		if len(parts) != 5 {
+			continue
+		}
+		entries[key] = inventoryEntry{Group: parts[0], Version: parts[1], Kind: parts[2], Namespace: parts[3], Name: name}
+	}
+	return entries
+}
+
+func inventoryConfigMap(name string, entries map[string]inventoryEntry) *v1.ConfigMap {
+	data := make(map[string]string, len(entries))
+	for key, entry := range entries {
+		data[key] = entry.Name
+	}
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Data:       data,
+	}
+}
+
+func createOrUpdateConfigMap(cms corev1client.ConfigMapInterface, cm *v1.ConfigMap) error {
+	if _, err := cms.Create(cm); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return err
+		}
+		existing, err := cms.Get(cm.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		cm.ResourceVersion = existing.ResourceVersion
+		if _, err := cms.Update(cm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteInventoryEntry deletes a single tracked object by GVK and name using
+// the same REST-mapping-based helper kubectl's own delete command uses, since
+// a pruned object's kind isn't known in advance and may not be one of the
+// batch/apps types run.go otherwise special-cases.
+func deleteInventoryEntry(f cmdutil.Factory, entry inventoryEntry) error {
+	gvk := schema.GroupVersionKind{Group: entry.Group, Version: entry.Version, Kind: entry.Kind}
+	mapper, err := f.ToRESTMapper()
+	if err != nil {
+		return err
+	}
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return err
+	}
+	client, err := f.ClientForMapping(mapping)
+	if err != nil {
+		return err
+	}
+	helper := resource.NewHelper(client, mapping)
+	_, err = helper.Delete(entry.Namespace, entry.Name)
+	return err
+}
+
+// getRestartPolicy returns the restart policy named by the "restart" flag,
+// defaulting based on whether the run is interactive.
+func getRestartPolicy(cmd *cobra.Command, interactive bool) (api.RestartPolicy, error) {
+	restart := cmdutil.GetFlagString(cmd, "restart")
+	if len(restart) == 0 {
+		if interactive {
+			return api.RestartPolicyOnFailure, nil
+		}
+		return api.RestartPolicyAlways, nil
+	}
+	switch api.RestartPolicy(restart) {
+	case api.RestartPolicyAlways:
+		return api.RestartPolicyAlways, nil
+	case api.RestartPolicyOnFailure:
+		return api.RestartPolicyOnFailure, nil
+	case api.RestartPolicyNever:
+		return api.RestartPolicyNever, nil
+	}
+	return "", cmdutil.UsageErrorf(cmd, "invalid restart policy: %s", restart)
+}
+
+// imageNameRegexp is a conservative approximation of the docker image name
+// grammar (registry/repository:tag@digest) sufficient to reject garbage like
+// "#" before it ever reaches the API server.
+var imageNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9._:/@-]*[a-zA-Z0-9])?$`)
+
+func verifyImageIsValid(image string) error {
+	if !imageNameRegexp.MatchString(image) {
+		return fmt.Errorf("Invalid image name %q: %v", image, "doesn't match docker image reference format")
+	}
+	return nil
+}
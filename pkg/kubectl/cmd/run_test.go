@@ -25,6 +25,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -33,10 +34,12 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
 	restclient "k8s.io/client-go/rest"
-	"k8s.io/client-go/rest/fake"
+	fakerest "k8s.io/client-go/rest/fake"
 	"k8s.io/kubernetes/pkg/api/legacyscheme"
 	api "k8s.io/kubernetes/pkg/apis/core"
+	"k8s.io/kubernetes/pkg/kubectl"
 	cmdtesting "k8s.io/kubernetes/pkg/kubectl/cmd/testing"
 	cmdutil "k8s.io/kubernetes/pkg/kubectl/cmd/util"
 	"k8s.io/kubernetes/pkg/kubectl/scheme"
@@ -105,6 +108,94 @@ func TestGetRestartPolicy(t *testing.T) {
 	}
 }
 
+func TestResolveWaitFlag(t *testing.T) {
+	tests := []struct {
+		name        string
+		flags       map[string]string
+		expectWait  *bool
+		expectErr   string
+		expectNotes string
+	}{
+		{
+			name: "nothing set defers to the caller",
+		},
+		{
+			name:       "--wait",
+			flags:      map[string]string{"wait": "true"},
+			expectWait: boolPtr(true),
+		},
+		{
+			name:       "--no-wait",
+			flags:      map[string]string{"no-wait": "true"},
+			expectWait: boolPtr(false),
+		},
+		{
+			name:      "--wait and --no-wait conflict",
+			flags:     map[string]string{"wait": "true", "no-wait": "true"},
+			expectErr: "mutually exclusive",
+		},
+		{
+			name:        "--async=true behaves like --no-wait and warns",
+			flags:       map[string]string{"async": "true"},
+			expectWait:  boolPtr(false),
+			expectNotes: "deprecated",
+		},
+		{
+			name:        "--async=false behaves like --wait and warns",
+			flags:       map[string]string{"async": "false"},
+			expectWait:  boolPtr(true),
+			expectNotes: "deprecated",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			addWaitFlags(cmd, "thing")
+			for name, value := range test.flags {
+				cmd.Flags().Set(name, value)
+			}
+
+			errBuf := &bytes.Buffer{}
+			wait, err := resolveWaitFlag(cmd, errBuf)
+			if len(test.expectErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.expectErr) {
+					t.Fatalf("expected error containing %q, got %v", test.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (wait == nil) != (test.expectWait == nil) || (wait != nil && *wait != *test.expectWait) {
+				t.Errorf("expected wait=%v, got %v", test.expectWait, wait)
+			}
+			if len(test.expectNotes) > 0 && !strings.Contains(errBuf.String(), test.expectNotes) {
+				t.Errorf("expected deprecation notice containing %q, got %q", test.expectNotes, errBuf.String())
+			}
+		})
+	}
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestGeneratorDefaultWait(t *testing.T) {
+	tests := []struct {
+		generator string
+		expected  bool
+	}{
+		{generator: "job/v1", expected: true},
+		{generator: "run-pod/v1", expected: true},
+		{generator: "deployment/apps.v1beta1", expected: false},
+		{generator: "run/v1", expected: false},
+	}
+	for _, test := range tests {
+		if got := generatorDefaultWait(test.generator); got != test.expected {
+			t.Errorf("generatorDefaultWait(%q) = %v, want %v", test.generator, got, test.expected)
+		}
+	}
+}
+
 func TestGetEnv(t *testing.T) {
 	test := struct {
 		input    []string
@@ -176,10 +267,10 @@ func TestRunArgsFollowDashRules(t *testing.T) {
 			codec := legacyscheme.Codecs.LegacyCodec(scheme.Versions...)
 			ns := legacyscheme.Codecs
 
-			tf.Client = &fake.RESTClient{
+			tf.Client = &fakerest.RESTClient{
 				GroupVersion:         schema.GroupVersion{Version: "v1"},
 				NegotiatedSerializer: ns,
-				Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+				Client: fakerest.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
 					if req.URL.Path == "/namespaces/test/replicationcontrollers" {
 						return &http.Response{StatusCode: 201, Header: defaultHeader(), Body: objBody(codec, rc)}, nil
 					}
@@ -234,6 +325,386 @@ func TestRunArgsFollowDashRules(t *testing.T) {
 	}
 }
 
+func TestWaitForPods(t *testing.T) {
+	notReadyPod := func(name string) v1.Pod {
+		return v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test", Labels: map[string]string{"run": "foo"}},
+			Status:     v1.PodStatus{Phase: v1.PodPending},
+		}
+	}
+	readyPod := func(name string) v1.Pod {
+		return v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test", Labels: map[string]string{"run": "foo"}},
+			Status: v1.PodStatus{
+				Phase:      v1.PodRunning,
+				Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		pods      []v1.Pod
+		flips     []v1.Pod
+		timeout   time.Duration
+		expectErr bool
+	}{
+		{
+			name:    "already ready",
+			pods:    []v1.Pod{readyPod("foo-1")},
+			timeout: time.Second,
+		},
+		{
+			name:    "flips to ready before timeout",
+			pods:    []v1.Pod{notReadyPod("foo-1")},
+			flips:   []v1.Pod{readyPod("foo-1")},
+			timeout: 5 * time.Second,
+		},
+		{
+			name:      "times out while pod stays unready",
+			pods:      []v1.Pod{notReadyPod("foo-1")},
+			timeout:   time.Second,
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objs := make([]runtime.Object, 0, len(test.pods))
+			for i := range test.pods {
+				objs = append(objs, &test.pods[i])
+			}
+			clientset := fake.NewSimpleClientset(objs...)
+
+			if len(test.flips) > 0 {
+				go func() {
+					time.Sleep(100 * time.Millisecond)
+					for _, p := range test.flips {
+						clientset.CoreV1().Pods(p.Namespace).Update(&p)
+					}
+				}()
+			}
+
+			opts := &RunOpts{ErrOut: ioutil.Discard}
+			err := opts.waitForPods(clientset, "test", "run=foo", "", len(test.pods), test.timeout)
+			if test.expectErr && err == nil {
+				t.Errorf("%s: expected timeout error, got none", test.name)
+			}
+			if !test.expectErr && err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			if test.expectErr {
+				if _, ok := err.(*waitTimeoutError); !ok {
+					t.Errorf("%s: expected *waitTimeoutError, got %T", test.name, err)
+				}
+			}
+		})
+	}
+}
+
+// TestWaitForReadinessPodPath drives waitForReadiness's *v1.Pod branch
+// directly (rather than calling waitForPods with a hand-picked selector), so
+// it would have caught the bare-Pod case being tracked by a label selector
+// that no Pod ever actually carries: the fake clientset's generated List only
+// filters on LabelSelector, so that bug manifested here as the pod never
+// matching and the wait timing out.
+func TestWaitForReadinessPodPath(t *testing.T) {
+	tf := cmdtesting.NewTestFactory()
+	defer tf.Cleanup()
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "test"},
+		Status: v1.PodStatus{
+			Phase:      v1.PodRunning,
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+		},
+	}
+	tf.ClientSet = fake.NewSimpleClientset(pod)
+
+	opts := &RunOpts{ErrOut: ioutil.Discard}
+	if err := opts.waitForReadiness(tf, pod, time.Second); err != nil {
+		t.Fatalf("expected the bare Pod to be tracked by name, got: %v", err)
+	}
+}
+
+func TestWaitForPodsReportsActualReadyContainerCount(t *testing.T) {
+	pod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo-1", Namespace: "test", Labels: map[string]string{"run": "foo"}},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "a"}, {Name: "b"}}},
+		Status: v1.PodStatus{
+			Phase: v1.PodRunning,
+			ContainerStatuses: []v1.ContainerStatus{
+				{Name: "a", Ready: true},
+				{Name: "b", Ready: false},
+			},
+		},
+	}
+	clientset := fake.NewSimpleClientset(&pod)
+
+	errOut := &bytes.Buffer{}
+	opts := &RunOpts{ErrOut: errOut}
+	err := opts.waitForPods(clientset, "test", "run=foo", "", 1, 500*time.Millisecond)
+	if err == nil {
+		t.Fatal("expected a timeout error since the pod never becomes fully ready")
+	}
+	if !strings.Contains(errOut.String(), "foo-1 (1/2 ready)") {
+		t.Errorf("expected progress output to report the actual ready count, got: %q", errOut.String())
+	}
+}
+
+func TestValidateGeneratedObject(t *testing.T) {
+	tf := cmdtesting.NewTestFactory()
+	defer tf.Cleanup()
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}
+
+	t.Run("validate disabled is a no-op", func(t *testing.T) {
+		opts := &RunOpts{Validate: false}
+		if err := opts.validateGeneratedObject(tf, pod); err != nil {
+			t.Errorf("unexpected error with --validate=false: %v", err)
+		}
+	})
+
+	t.Run("validate enabled without a reachable discovery client surfaces a clear error", func(t *testing.T) {
+		opts := &RunOpts{Validate: true}
+		err := opts.validateGeneratedObject(tf, pod)
+		if err == nil {
+			t.Fatal("expected an error when the OpenAPI schema can't be loaded")
+		}
+		if !strings.Contains(err.Error(), "unable to load schema for --validate") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+// fakeSchema is a stub validation.Schema so validateAgainstSchema can be
+// tested without a live discovery client or bundled OpenAPI document.
+type fakeSchema struct{ err error }
+
+func (f fakeSchema) ValidateBytes(data []byte) error { return f.err }
+
+func TestValidateAgainstSchema(t *testing.T) {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "foo"}}
+
+	t.Run("schema rejection surfaces the offending field path", func(t *testing.T) {
+		err := validateAgainstSchema(fakeSchema{err: fmt.Errorf("spec.containers[0].resources.limits.cpu: invalid quantity")}, pod)
+		if err == nil || !strings.Contains(err.Error(), "invalid quantity") {
+			t.Fatalf("expected a schema validation error naming the field, got %v", err)
+		}
+	})
+
+	t.Run("schema acceptance is a no-op", func(t *testing.T) {
+		if err := validateAgainstSchema(fakeSchema{}, pod); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+// recordingSchema captures the bytes it was asked to validate, so a test can
+// assert on exactly what reached the schema.
+type recordingSchema struct {
+	validated []byte
+}
+
+func (r *recordingSchema) ValidateBytes(data []byte) error {
+	r.validated = data
+	return nil
+}
+
+// TestRunValidatesOverriddenObject reproduces Run()'s own "generate, merge
+// --overrides, then validate" sequence and asserts that the bytes handed to
+// the schema validator contain the override - not the pre-override object.
+// Before the ordering fix, validateGeneratedObject ran against generator
+// output alone and --overrides was merged afterwards inside
+// CreateOrUpdateObject, so a field introduced only by --overrides (a
+// misspelled --overrides key, or any value --validate was supposed to catch)
+// was never seen by --validate at all.
+func TestRunValidatesOverriddenObject(t *testing.T) {
+	generators := kubectl.GeneratorFn("run-pod/v1")
+	generator, found := generators("foo")
+	if !found {
+		t.Fatal("expected the run-pod/v1 generator to be registered")
+	}
+	obj, err := generator.Generate(map[string]interface{}{"name": "foo", "image": "busybox"})
+	if err != nil {
+		t.Fatalf("unexpected error generating object: %v", err)
+	}
+
+	overrides := `{"metadata":{"labels":{"run-overridden":"yes"}}}`
+	merged, err := cmdutil.Merge(legacyscheme.Codecs.LegacyCodec(scheme.Versions...), obj, overrides)
+	if err != nil {
+		t.Fatalf("unexpected error merging --overrides: %v", err)
+	}
+
+	schema := &recordingSchema{}
+	if err := validateAgainstSchema(schema, merged); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(schema.validated), "run-overridden") {
+		t.Errorf("expected the validated object to include the --overrides content, got: %s", schema.validated)
+	}
+}
+
+func TestReconcileInventoryPrune(t *testing.T) {
+	tf := cmdtesting.NewTestFactory()
+	defer tf.Cleanup()
+
+	namespace := "test"
+	tf.Namespace = namespace
+	tf.ClientConfigVal = defaultClientConfig()
+
+	// Neither object has its GroupVersionKind hand-set here: production
+	// objects coming back from CreateOrUpdateObject/generateService carry a
+	// typed Go value with an empty TypeMeta, so reconcileInventory must be
+	// able to resolve the GVK itself (via gvkForObject's scheme fallback) for
+	// the inventory entries, and for the later delete, to be distinguishable.
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: namespace}}
+	svc := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: namespace}}
+
+	clientset := fake.NewSimpleClientset()
+	tf.ClientSet = clientset
+
+	var deletedPaths []string
+	codec := legacyscheme.Codecs.LegacyCodec(scheme.Versions...)
+	tf.Client = &fakerest.RESTClient{
+		GroupVersion:         schema.GroupVersion{Version: "v1"},
+		NegotiatedSerializer: legacyscheme.Codecs,
+		Client: fakerest.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			if req.Method == "DELETE" {
+				deletedPaths = append(deletedPaths, req.URL.Path)
+			}
+			return &http.Response{StatusCode: 200, Header: defaultHeader(), Body: objBody(codec, svc)}, nil
+		}),
+	}
+
+	opts := &RunOpts{Namespace: namespace, Prune: true, InventoryName: "inv"}
+
+	// First pass: both the Pod and the Service are tracked.
+	if err := opts.reconcileInventory(tf, []runtime.Object{pod, svc}); err != nil {
+		t.Fatalf("first pass: unexpected error: %v", err)
+	}
+	cm, err := clientset.CoreV1().ConfigMaps(namespace).Get("inv", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected inventory configmap to exist: %v", err)
+	}
+	if len(cm.Data) != 2 {
+		t.Fatalf("expected 2 tracked entries, got %d: %v", len(cm.Data), cm.Data)
+	}
+
+	// Second pass: only the Pod is produced, so the Service must be pruned.
+	if err := opts.reconcileInventory(tf, []runtime.Object{pod}); err != nil {
+		t.Fatalf("second pass: unexpected error: %v", err)
+	}
+	if len(deletedPaths) != 1 {
+		t.Fatalf("expected exactly one DELETE for the dropped service, got %v", deletedPaths)
+	}
+	cm, err = clientset.CoreV1().ConfigMaps(namespace).Get("inv", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected inventory configmap to still exist: %v", err)
+	}
+	if len(cm.Data) != 1 {
+		t.Errorf("expected 1 tracked entry after prune, got %d: %v", len(cm.Data), cm.Data)
+	}
+	if _, err := clientset.CoreV1().ConfigMaps(namespace).Get("inv"+inventoryNewSuffix, metav1.GetOptions{}); err == nil {
+		t.Errorf("expected the staging configmap to be cleaned up after the swap")
+	}
+}
+
+func TestReconcileInventoryRecoversFromCrash(t *testing.T) {
+	tf := cmdtesting.NewTestFactory()
+	defer tf.Cleanup()
+
+	namespace := "test"
+	tf.Namespace = namespace
+	tf.ClientConfigVal = defaultClientConfig()
+
+	// As in TestReconcileInventoryPrune, GVKs are deliberately left unset so
+	// this exercises gvkForObject's scheme-based fallback rather than hiding
+	// behind a hand-set TypeMeta the production Run() path never sets.
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: namespace}}
+	staleSvc := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "stale", Namespace: namespace}}
+
+	clientset := fake.NewSimpleClientset()
+	tf.ClientSet = clientset
+
+	// Simulate a crash between staging the new inventory and pruning/swapping:
+	// only the "-new" ConfigMap exists, recording a run that believed it had
+	// created both the Pod and a Service that no longer exists in this run's
+	// object set.
+	staleEntries := map[string]inventoryEntry{}
+	for _, obj := range []runtime.Object{pod, staleSvc} {
+		entry, err := inventoryEntryFor(obj, namespace)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		staleEntries[entry.key()] = entry
+	}
+	staleCM := inventoryConfigMap("inv"+inventoryNewSuffix, staleEntries)
+	if _, err := clientset.CoreV1().ConfigMaps(namespace).Create(staleCM); err != nil {
+		t.Fatalf("unexpected error seeding stale inventory: %v", err)
+	}
+
+	var deletedPaths []string
+	codec := legacyscheme.Codecs.LegacyCodec(scheme.Versions...)
+	tf.Client = &fakerest.RESTClient{
+		GroupVersion:         schema.GroupVersion{Version: "v1"},
+		NegotiatedSerializer: legacyscheme.Codecs,
+		Client: fakerest.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			if req.Method == "DELETE" {
+				deletedPaths = append(deletedPaths, req.URL.Path)
+			}
+			return &http.Response{StatusCode: 200, Header: defaultHeader(), Body: objBody(codec, staleSvc)}, nil
+		}),
+	}
+
+	opts := &RunOpts{Namespace: namespace, Prune: true, InventoryName: "inv"}
+	if err := opts.reconcileInventory(tf, []runtime.Object{pod}); err != nil {
+		t.Fatalf("unexpected error reconciling after a crash: %v", err)
+	}
+	if len(deletedPaths) != 1 {
+		t.Fatalf("expected the stale service to be pruned on recovery, got deletes: %v", deletedPaths)
+	}
+	if _, err := clientset.CoreV1().ConfigMaps(namespace).Get("inv"+inventoryNewSuffix, metav1.GetOptions{}); err == nil {
+		t.Errorf("expected the stale staging configmap to be cleaned up")
+	}
+}
+
+// fake.NewSimpleClientset's tracker doesn't enforce optimistic concurrency,
+// so this asserts directly on the ResourceVersion createOrUpdateConfigMap
+// sends rather than relying on an Update call failing.
+func TestCreateOrUpdateConfigMapCarriesResourceVersionOnUpdate(t *testing.T) {
+	namespace := "test"
+	clientset := fake.NewSimpleClientset()
+	cms := clientset.CoreV1().ConfigMaps(namespace)
+
+	first := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "inv", Namespace: namespace}, Data: map[string]string{"a": "1"}}
+	if err := createOrUpdateConfigMap(cms, first); err != nil {
+		t.Fatalf("unexpected error creating: %v", err)
+	}
+	created, err := cms.Get("inv", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error fetching created configmap: %v", err)
+	}
+	if len(created.ResourceVersion) == 0 {
+		t.Fatal("expected the fake clientset to assign a ResourceVersion on create")
+	}
+
+	second := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "inv", Namespace: namespace}, Data: map[string]string{"a": "2"}}
+	if err := createOrUpdateConfigMap(cms, second); err != nil {
+		t.Fatalf("unexpected error updating: %v", err)
+	}
+	if second.ResourceVersion != created.ResourceVersion {
+		t.Errorf("expected createOrUpdateConfigMap to stamp the existing ResourceVersion %q onto the update, got %q", created.ResourceVersion, second.ResourceVersion)
+	}
+	updated, err := cms.Get("inv", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error fetching updated configmap: %v", err)
+	}
+	if updated.Data["a"] != "2" {
+		t.Errorf("expected update to take effect, got data: %v", updated.Data)
+	}
+}
+
 func TestGenerateService(t *testing.T) {
 
 	tests := []struct {
@@ -335,10 +806,10 @@ func TestGenerateService(t *testing.T) {
 			ns := legacyscheme.Codecs
 
 			tf.ClientConfigVal = defaultClientConfig()
-			tf.Client = &fake.RESTClient{
+			tf.Client = &fakerest.RESTClient{
 				GroupVersion:         schema.GroupVersion{Version: "v1"},
 				NegotiatedSerializer: ns,
-				Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+				Client: fakerest.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
 					switch p, m := req.URL.Path, req.Method; {
 					case test.expectPOST && m == "POST" && p == "/namespaces/namespace/services":
 						sawPOST = true
@@ -505,6 +976,55 @@ func TestRunValidations(t *testing.T) {
 			},
 			expectedErr: "stdin is required for containers with -t/--tty",
 		},
+		{
+			// This rejects inside generator.Generate's ResourceList parsing,
+			// before Run() ever reaches validateGeneratedObject - it is
+			// generator-level coverage, not --validate coverage. The
+			// --validate code path itself is covered directly by
+			// TestValidateAgainstSchema, which doesn't depend on generator
+			// parsing failing first.
+			name: "test bogus --limits value rejected by the generator",
+			args: []string{"test"},
+			flags: map[string]string{
+				"image":  "busybox",
+				"limits": "cpu=notaquantity",
+			},
+			expectedErr: "invalid quantity",
+		},
+		{
+			// Same generator-level parsing as --limits above, just the
+			// --requests flag.
+			name: "test bogus --requests value rejected by the generator",
+			args: []string{"test"},
+			flags: map[string]string{
+				"image":    "busybox",
+				"requests": "cpu=notaquantity",
+			},
+			expectedErr: "invalid quantity",
+		},
+		{
+			// Unlike the --limits/--requests cases above, this goes through
+			// the --overrides merge step in Run(), which now runs (and can
+			// reject) before --validate, and before CreateOrUpdateObject
+			// ever POSTs anything.
+			name: "test malformed --overrides value rejected before any HTTP POST",
+			args: []string{"test"},
+			flags: map[string]string{
+				"image":     "busybox",
+				"overrides": "{not valid json}",
+			},
+			expectedErr: "invalid original object",
+		},
+		{
+			name: "test --wait and --no-wait conflict",
+			args: []string{"test"},
+			flags: map[string]string{
+				"image":   "busybox",
+				"wait":    "true",
+				"no-wait": "true",
+			},
+			expectedErr: "mutually exclusive",
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -512,7 +1032,7 @@ func TestRunValidations(t *testing.T) {
 			defer tf.Cleanup()
 
 			_, _, codec := cmdtesting.NewExternalScheme()
-			tf.Client = &fake.RESTClient{
+			tf.Client = &fakerest.RESTClient{
 				NegotiatedSerializer: scheme.Codecs,
 				Resp:                 &http.Response{StatusCode: 200, Header: defaultHeader(), Body: objBody(codec, cmdtesting.NewInternalType("", "", ""))},
 			}